@@ -17,14 +17,21 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/minio/mc/pkg/console"
 	"github.com/minio/pb"
+	"github.com/vbauerster/mpb"
+	"github.com/vbauerster/mpb/decor"
 )
 
 type cpBarCmd int
@@ -36,21 +43,41 @@ const (
 	cpBarCmdPutError
 	cpBarCmdGetError
 	cpBarCmdSetCaption
+	cpBarCmdResume
+	cpBarCmdCategorize
+	cpBarCmdTally
 )
 
+// tallyArg is the Arg payload for cpBarCmdTally: add N to category.
+type tallyArg struct {
+	Category string
+	N        int64
+}
+
 type copyReader struct {
 	io.Reader
 	bar *barSend
+	id  string
 }
 
 func (r *copyReader) Read(p []byte) (n int, err error) {
 	n, err = r.Reader.Read(p)
-	r.bar.progress(int64(n))
+	r.bar.progress(r.id, int64(n))
 	return
 }
 
+// Resume tells the bar that the first offset bytes of this transfer were
+// already accounted for in a previous, failed attempt, so the progress
+// deltas the retried Read produces for them must not be double-counted.
+func (r *copyReader) Resume(offset int64) {
+	r.bar.Resume(r.id, offset)
+}
+
+// barMsg is routed to the bar goroutine and keyed by transfer ID so that
+// concurrent transfers can each drive their own mpb.Bar independently.
 type barMsg struct {
 	Cmd cpBarCmd
+	ID  string
 	Arg interface{}
 }
 
@@ -59,24 +86,44 @@ type barSend struct {
 	finishCh <-chan bool
 }
 
-func (b barSend) Extend(total int64) {
-	b.cmdCh <- barMsg{Cmd: cpBarCmdExtend, Arg: total}
+func (b barSend) Extend(id string, total int64) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdExtend, ID: id, Arg: total}
+}
+
+func (b barSend) progress(id string, progress int64) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdProgress, ID: id, Arg: progress}
 }
 
-func (b barSend) progress(progress int64) {
-	b.cmdCh <- barMsg{Cmd: cpBarCmdProgress, Arg: progress}
+func (b barSend) ErrorPut(id string, size int64) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdPutError, ID: id, Arg: size}
 }
 
-func (b barSend) ErrorPut(size int64) {
-	b.cmdCh <- barMsg{Cmd: cpBarCmdPutError, Arg: size}
+func (b barSend) ErrorGet(id string, size int64) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdGetError, ID: id, Arg: size}
 }
 
-func (b barSend) ErrorGet(size int64) {
-	b.cmdCh <- barMsg{Cmd: cpBarCmdGetError, Arg: size}
+// Resume marks offset bytes of transfer id as already accounted for by a
+// previous attempt, so the retry's progress deltas below that checkpoint
+// are ignored rather than double-counted.
+func (b barSend) Resume(id string, offset int64) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdResume, ID: id, Arg: offset}
 }
 
-func (b *barSend) NewProxyReader(r io.Reader) *copyReader {
-	return &copyReader{r, b}
+// Categorize registers a session-summary bucket (e.g. "files copied",
+// "files skipped") so it appears in the final summary table even if
+// Tally is never called for it.
+func (b barSend) Categorize(name string) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdCategorize, Arg: name}
+}
+
+// Tally adds n to the named session-summary bucket, creating it if it
+// hasn't been seen yet.
+func (b barSend) Tally(category string, n int64) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdTally, Arg: tallyArg{Category: category, N: n}}
+}
+
+func (b *barSend) NewProxyReader(id string, r io.Reader) *copyReader {
+	return &copyReader{r, b, id}
 }
 
 type caption struct {
@@ -84,8 +131,8 @@ type caption struct {
 	separator rune
 }
 
-func (b *barSend) SetCaption(c caption) {
-	b.cmdCh <- barMsg{Cmd: cpBarCmdSetCaption, Arg: c}
+func (b *barSend) SetCaption(id string, c caption) {
+	b.cmdCh <- barMsg{Cmd: cpBarCmdSetCaption, ID: id, Arg: c}
 }
 
 func (b barSend) Finish() {
@@ -94,6 +141,83 @@ func (b barSend) Finish() {
 	<-b.finishCh
 }
 
+// resolveBarWidth figures out how many columns are available for drawing,
+// trying pb.GetTerminalWidth() first, then $COLUMNS, and finally falling
+// back to a hard default of 80. isTTY is false when stdout is not a
+// character device, or once every real signal has been exhausted; either is
+// our cue to degrade to plain-line output.
+func resolveBarWidth() (width int, isTTY bool) {
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice == 0 {
+		return 80, false
+	}
+	if w, err := pb.GetTerminalWidth(); err == nil && w > 0 {
+		return w, true
+	}
+	if cols := os.Getenv("COLUMNS"); cols != "" {
+		if w, err := strconv.Atoi(cols); err == nil && w > 0 {
+			return w, true
+		}
+	}
+	return 80, false
+}
+
+// applyCheckpoint subtracts as much of n as falls within the outstanding
+// checkpoint left by a Resume call, returning only the remainder that
+// hasn't already been accounted for by a previous attempt.
+func applyCheckpoint(checkpoint *int64, n int64) int64 {
+	skip := *checkpoint
+	if skip <= 0 {
+		return n
+	}
+	if n <= skip {
+		*checkpoint = skip - n
+		return 0
+	}
+	*checkpoint = 0
+	return n - skip
+}
+
+// summary accumulates the session-wide tallies fed by Categorize/Tally,
+// in the order the categories were first seen, for a final report.
+type summary struct {
+	order   []string
+	tallies map[string]int64
+}
+
+func newSummary() *summary {
+	return &summary{tallies: make(map[string]int64)}
+}
+
+func (s *summary) categorize(name string) {
+	if _, ok := s.tallies[name]; !ok {
+		s.tallies[name] = 0
+		s.order = append(s.order, name)
+	}
+}
+
+func (s *summary) tally(category string, n int64) {
+	s.categorize(category)
+	s.tallies[category] += n
+}
+
+// print renders the accumulated tallies as a scannable table, analogous to
+// Docker CLI's disk-usage verbose output.
+func (s *summary) print() {
+	if len(s.order) == 0 {
+		return
+	}
+	width := 0
+	for _, name := range s.order {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	console.Println("Summary:")
+	for _, name := range s.order {
+		console.Println(fmt.Sprintf("  %-*s  %d", width, name, s.tallies[name]))
+	}
+}
+
 func trimBarCaption(c caption, width int) string {
 	if len(c.message) > width {
 		// Trim caption to fit within the screen
@@ -110,63 +234,350 @@ func trimBarCaption(c caption, width int) string {
 	return c.message
 }
 
-// newCpBar - instantiate a cpBar.
-func newCpBar() barSend {
+// cpBars owns the mpb.Progress container along with one bar per in-flight
+// transfer plus the aggregate "total" bar rendered beneath them.
+type cpBars struct {
+	progress *mpb.Progress
+	bars     map[string]*mpb.Bar
+	capMu    sync.Mutex // guards captions, read concurrently by mpb's render goroutine
+	captions map[string]caption
+	total    *mpb.Bar
+	width    int32            // current terminal width, kept fresh by watchResize
+	resumed  map[string]int64 // per-transfer checkpoint left by a Resume call
+	read     map[string]int64 // per-transfer bytes read so far, for accurate error rewinds
+	totals   map[string]int64 // per-transfer total set so far, since *mpb.Bar exposes no getter for it
+	totalAmt int64            // aggregate of totals, mirrored onto the "total" bar
+}
+
+func newCpBars(width int) *cpBars {
+	p := mpb.New()
+	return &cpBars{
+		progress: p,
+		bars:     make(map[string]*mpb.Bar),
+		captions: make(map[string]caption),
+		resumed:  make(map[string]int64),
+		read:     make(map[string]int64),
+		totals:   make(map[string]int64),
+		total: p.AddBar(0,
+			mpb.BarPriority(math.MaxInt32),
+			mpb.PrependDecorators(decor.Name("total")),
+			mpb.AppendDecorators(decor.CountersKibiByte("%6.1f / %6.1f"))),
+		width: int32(width),
+	}
+}
+
+// setCaption records the raw caption for id, to be re-trimmed against the
+// current width on every render rather than baked in once.
+func (c *cpBars) setCaption(id string, cap caption) {
+	c.capMu.Lock()
+	c.captions[id] = cap
+	c.capMu.Unlock()
+}
+
+// captionFor returns id's caption freshly trimmed to the current width, so
+// a SIGWINCH resize reformats bars that are already on screen.
+func (c *cpBars) captionFor(id string) string {
+	c.capMu.Lock()
+	cap := c.captions[id]
+	c.capMu.Unlock()
+	return trimBarCaption(cap, int(atomic.LoadInt32(&c.width)))
+}
+
+// captionDecorator renders captionFor(id) fresh on every render tick,
+// instead of baking the string in once like decor.Name does, so a
+// SIGWINCH-driven width change re-trims captions already on screen.
+type captionDecorator struct {
+	decor.WC
+	fn func() string
+}
+
+func (d *captionDecorator) Decor(*decor.Statistics) string {
+	return d.FormatMsg(d.fn())
+}
+
+func newCaptionDecorator(fn func() string) decor.Decorator {
+	var wc decor.WC
+	wc.Init()
+	return &captionDecorator{WC: wc, fn: fn}
+}
+
+// barFor returns the mpb.Bar for id, creating it on first use. The caption
+// decorator re-reads captionFor(id) on every render tick, so it stays
+// correct across SetCaption and width changes.
+func (c *cpBars) barFor(id string) *mpb.Bar {
+	bar, ok := c.bars[id]
+	if !ok {
+		bar = c.progress.AddBar(0,
+			mpb.PrependDecorators(newCaptionDecorator(func() string { return c.captionFor(id) })),
+			mpb.AppendDecorators(decor.CountersKibiByte("%6.1f / %6.1f")))
+		c.bars[id] = bar
+	}
+	return bar
+}
+
+// progressEvent is a single newline-delimited JSON progress event, emitted
+// in place of a drawn bar when jsonMode is active.
+type progressEvent struct {
+	Type        string  `json:"type"`
+	Object      string  `json:"object"`
+	Transferred int64   `json:"transferred"`
+	Total       int64   `json:"total"`
+	SpeedBps    float64 `json:"speedBps"`
+	TS          string  `json:"ts"`
+}
+
+// summaryEvent reports the final session tallies (e.g. files copied,
+// files skipped, bytes transferred) as a single closing JSON event.
+type summaryEvent struct {
+	Type    string           `json:"type"`
+	Tallies map[string]int64 `json:"tallies"`
+}
+
+// jsonXferState tracks the per-transfer counters needed to compute speedBps
+// for each emitted event.
+type jsonXferState struct {
+	object      string
+	total       int64
+	transferred int64
+	checkpoint  int64 // bytes already accounted for by a previous attempt
+	start       time.Time
+}
+
+// jsonBars emits a progressEvent on extend, counted progress, resume, put
+// and get errors, and one per transfer on finish (followed by a
+// summaryEvent), instead of drawing a bar, for consumption by scripts, CI,
+// and wrapper tools. Caption, categorize and tally commands emit nothing.
+type jsonBars struct {
+	enc   *json.Encoder
+	xfers map[string]*jsonXferState
+}
+
+func newJSONBars() *jsonBars {
+	return &jsonBars{
+		enc:   json.NewEncoder(os.Stdout),
+		xfers: make(map[string]*jsonXferState),
+	}
+}
+
+func (j *jsonBars) xferFor(id string) *jsonXferState {
+	x, ok := j.xfers[id]
+	if !ok {
+		x = &jsonXferState{start: time.Now()}
+		j.xfers[id] = x
+	}
+	return x
+}
+
+func (j *jsonBars) emit(typ string, x *jsonXferState) {
+	speed := float64(0)
+	if elapsed := time.Since(x.start).Seconds(); elapsed > 0 {
+		speed = float64(x.transferred) / elapsed
+	}
+	j.enc.Encode(progressEvent{
+		Type:        typ,
+		Object:      x.object,
+		Transferred: x.transferred,
+		Total:       x.total,
+		SpeedBps:    speed,
+		TS:          time.Now().UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// plainBars is the headless fallback used when stdout isn't a terminal and
+// jsonMode wasn't requested either: it prints a periodic "transferred X of
+// Y" line instead of drawing a bar that would only produce garbled output.
+type plainBars struct {
+	totalRead   int64
+	totalTarget int64
+	checkpoints map[string]int64 // per-transfer checkpoint left by a Resume call
+}
+
+func newPlainBars() *plainBars {
+	return &plainBars{checkpoints: make(map[string]int64)}
+}
+
+func (p *plainBars) line() string {
+	return fmt.Sprintf("transferred %d of %d bytes", atomic.LoadInt64(&p.totalRead), atomic.LoadInt64(&p.totalTarget))
+}
+
+// newCpBar - instantiate a cpBar, a container of per-transfer mpb bars plus
+// an aggregate total bar at the bottom, keyed by transfer ID. jsonMode is
+// decided entirely by the caller (true for an explicit --json) and is never
+// switched on by probing stdout. When jsonMode is true,
+// newline-delimited JSON progress events are emitted instead of drawn
+// bars; otherwise a non-terminal stdout falls back to the plain periodic
+// line below rather than JSON.
+func newCpBar(jsonMode bool) barSend {
 	cmdCh := make(chan barMsg)
 	finishCh := make(chan bool)
+	if jsonMode {
+		go func(cmdCh <-chan barMsg, finishCh chan<- bool) {
+			j := newJSONBars()
+			sum := newSummary()
+			for msg := range cmdCh {
+				switch msg.Cmd {
+				case cpBarCmdCategorize:
+					sum.categorize(msg.Arg.(string))
+				case cpBarCmdTally:
+					t := msg.Arg.(tallyArg)
+					sum.tally(t.Category, t.N)
+				case cpBarCmdSetCaption:
+					j.xferFor(msg.ID).object = msg.Arg.(caption).message
+				case cpBarCmdExtend:
+					x := j.xferFor(msg.ID)
+					x.total += msg.Arg.(int64)
+					j.emit("extend", x)
+				case cpBarCmdProgress:
+					n := msg.Arg.(int64)
+					if n > 0 {
+						x := j.xferFor(msg.ID)
+						n = applyCheckpoint(&x.checkpoint, n)
+						if n > 0 {
+							x.transferred += n
+							j.emit("progress", x)
+						}
+					}
+				case cpBarCmdResume:
+					x := j.xferFor(msg.ID)
+					x.checkpoint += msg.Arg.(int64)
+					j.emit("resume", x)
+				case cpBarCmdPutError:
+					x := j.xferFor(msg.ID)
+					size := msg.Arg.(int64)
+					if x.transferred > size {
+						x.transferred -= size
+					}
+					j.emit("putError", x)
+				case cpBarCmdGetError:
+					x := j.xferFor(msg.ID)
+					x.transferred += msg.Arg.(int64)
+					j.emit("getError", x)
+				case cpBarCmdFinish:
+					for _, x := range j.xfers {
+						j.emit("finish", x)
+					}
+					j.enc.Encode(summaryEvent{Type: "summary", Tallies: sum.tallies})
+					finishCh <- true
+					return
+				}
+			}
+		}(cmdCh, finishCh)
+		return barSend{cmdCh, finishCh}
+	}
+	width, isTTY := resolveBarWidth()
+	if !isTTY {
+		go func(cmdCh <-chan barMsg, finishCh chan<- bool) {
+			p := newPlainBars()
+			sum := newSummary()
+			ticker := time.NewTicker(2 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case msg := <-cmdCh:
+					switch msg.Cmd {
+					case cpBarCmdCategorize:
+						sum.categorize(msg.Arg.(string))
+					case cpBarCmdTally:
+						t := msg.Arg.(tallyArg)
+						sum.tally(t.Category, t.N)
+					case cpBarCmdExtend:
+						atomic.AddInt64(&p.totalTarget, msg.Arg.(int64))
+					case cpBarCmdProgress:
+						if n := msg.Arg.(int64); n > 0 {
+							checkpoint := p.checkpoints[msg.ID]
+							n = applyCheckpoint(&checkpoint, n)
+							p.checkpoints[msg.ID] = checkpoint
+							if n > 0 {
+								atomic.AddInt64(&p.totalRead, n)
+							}
+						}
+					case cpBarCmdResume:
+						p.checkpoints[msg.ID] += msg.Arg.(int64)
+					case cpBarCmdPutError:
+						size := msg.Arg.(int64)
+						if atomic.LoadInt64(&p.totalRead) > size {
+							atomic.AddInt64(&p.totalRead, -size)
+						}
+					case cpBarCmdGetError:
+						atomic.AddInt64(&p.totalRead, msg.Arg.(int64))
+					case cpBarCmdFinish:
+						console.Println(p.line())
+						sum.print()
+						finishCh <- true
+						return
+					}
+				case <-ticker.C:
+					console.Println(p.line())
+				}
+			}
+		}(cmdCh, finishCh)
+		return barSend{cmdCh, finishCh}
+	}
 	go func(cmdCh <-chan barMsg, finishCh chan<- bool) {
-		var started bool
-		var redraw bool
-		var barCaption string
-		var totalBytesRead int64 // total amounts of bytes read
-		bar := pb.New64(0)
-		bar.SetUnits(pb.U_BYTES)
-		bar.SetRefreshRate(time.Millisecond * 10)
-		bar.NotPrint = true
-		bar.ShowSpeed = true
-		cursorUp := fmt.Sprintf("%c[%dA", 27, 1)
-		bar.Callback = func(s string) {
-			if redraw {
-				console.Bar("\n")
+		bars := newCpBars(width)
+		sum := newSummary()
+		stop := watchResize(func() {
+			if w, ok := resolveBarWidth(); ok {
+				atomic.StoreInt32(&bars.width, int32(w))
 			}
-			// Clear the caption line
-			console.Bar("\r" + cursorUp + strings.Repeat(" ", len(s)) + "\r")
-			// Print the caption and the progress bar
-			console.Bar(barCaption + "\n" + s)
-			redraw = false
-		}
-		// Feels like wget
-		bar.Format("[=> ]")
+		})
+		defer stop()
 		for msg := range cmdCh {
 			switch msg.Cmd {
+			case cpBarCmdCategorize:
+				sum.categorize(msg.Arg.(string))
+			case cpBarCmdTally:
+				t := msg.Arg.(tallyArg)
+				sum.tally(t.Category, t.N)
 			case cpBarCmdSetCaption:
-				barCaption = trimBarCaption(msg.Arg.(caption), bar.GetWidth())
+				bars.setCaption(msg.ID, msg.Arg.(caption))
 			case cpBarCmdExtend:
-				atomic.AddInt64(&bar.Total, msg.Arg.(int64))
+				total := msg.Arg.(int64)
+				bars.totals[msg.ID] += total
+				bars.barFor(msg.ID).SetTotal(bars.totals[msg.ID], false)
+				bars.totalAmt += total
+				bars.total.SetTotal(bars.totalAmt, false)
 			case cpBarCmdProgress:
-				if bar.Total > 0 && !started {
-					started = true
-					redraw = true
-					bar.Start()
+				n := msg.Arg.(int64)
+				if n > 0 {
+					checkpoint := bars.resumed[msg.ID]
+					n = applyCheckpoint(&checkpoint, n)
+					bars.resumed[msg.ID] = checkpoint
 				}
-				if msg.Arg.(int64) > 0 {
-					totalBytesRead += msg.Arg.(int64)
-					bar.Add64(msg.Arg.(int64))
+				if n > 0 {
+					bars.read[msg.ID] += n
+					bars.barFor(msg.ID).IncrBy(int(n))
+					bars.total.IncrBy(int(n))
 				}
+			case cpBarCmdResume:
+				bars.resumed[msg.ID] += msg.Arg.(int64)
 			case cpBarCmdPutError:
-				redraw = true
-				if totalBytesRead > msg.Arg.(int64) {
-					bar.Set64(totalBytesRead - msg.Arg.(int64))
+				size := msg.Arg.(int64)
+				read := bars.read[msg.ID]
+				rewind := size
+				if rewind > read {
+					rewind = read
+				}
+				if rewind > 0 {
+					bars.read[msg.ID] = read - rewind
+					if bar, ok := bars.bars[msg.ID]; ok {
+						bar.IncrBy(-int(rewind))
+					}
+					bars.total.IncrBy(-int(rewind))
 				}
 			case cpBarCmdGetError:
-				redraw = true
-				if msg.Arg.(int64) > 0 {
-					bar.Add64(msg.Arg.(int64))
+				size := msg.Arg.(int64)
+				if size > 0 {
+					bars.barFor(msg.ID).IncrBy(int(size))
+					bars.total.IncrBy(int(size))
 				}
 			case cpBarCmdFinish:
-				if started {
-					bar.Finish()
+				for _, bar := range bars.bars {
+					bar.SetTotal(bar.Current(), true)
 				}
+				bars.total.SetTotal(bars.total.Current(), true)
+				bars.progress.Wait()
+				sum.print()
 				finishCh <- true
 				return
 			}
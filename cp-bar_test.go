@@ -0,0 +1,132 @@
+/*
+ * Minio Client (C) 2014, 2015 Minio, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "testing"
+
+func TestApplyCheckpoint(t *testing.T) {
+	testCases := []struct {
+		checkpoint      int64
+		n               int64
+		expectCounted   int64
+		expectRemaining int64
+	}{
+		{checkpoint: 0, n: 100, expectCounted: 100, expectRemaining: 0},
+		{checkpoint: 50, n: 30, expectCounted: 0, expectRemaining: 20},
+		{checkpoint: 50, n: 50, expectCounted: 0, expectRemaining: 0},
+		{checkpoint: 50, n: 70, expectCounted: 20, expectRemaining: 0},
+		{checkpoint: -10, n: 30, expectCounted: 30, expectRemaining: -10},
+	}
+	for i, testCase := range testCases {
+		checkpoint := testCase.checkpoint
+		counted := applyCheckpoint(&checkpoint, testCase.n)
+		if counted != testCase.expectCounted {
+			t.Errorf("Test %d: expected counted %d, got %d", i, testCase.expectCounted, counted)
+		}
+		if checkpoint != testCase.expectRemaining {
+			t.Errorf("Test %d: expected remaining checkpoint %d, got %d", i, testCase.expectRemaining, checkpoint)
+		}
+	}
+}
+
+func TestTrimBarCaption(t *testing.T) {
+	testCases := []struct {
+		message   string
+		separator rune
+		width     int
+		expected  string
+	}{
+		{message: "short", separator: '/', width: 80, expected: "short"},
+		{message: "bucket/a/very/long/path/to/object.txt", separator: '/', width: 15, expected: "/object.txt"},
+	}
+	for i, testCase := range testCases {
+		got := trimBarCaption(caption{message: testCase.message, separator: testCase.separator}, testCase.width)
+		if got != testCase.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, testCase.expected, got)
+		}
+	}
+}
+
+func TestSummaryTallyOrder(t *testing.T) {
+	s := newSummary()
+	s.categorize("files skipped")
+	s.tally("files copied", 3)
+	s.tally("files copied", 2)
+	s.tally("bytes transferred", 1024)
+
+	expectedOrder := []string{"files skipped", "files copied", "bytes transferred"}
+	if len(s.order) != len(expectedOrder) {
+		t.Fatalf("expected %d categories, got %d", len(expectedOrder), len(s.order))
+	}
+	for i, name := range expectedOrder {
+		if s.order[i] != name {
+			t.Errorf("expected category %d to be %q, got %q", i, name, s.order[i])
+		}
+	}
+
+	if s.tallies["files skipped"] != 0 {
+		t.Errorf("expected untallied category to stay at 0, got %d", s.tallies["files skipped"])
+	}
+	if s.tallies["files copied"] != 5 {
+		t.Errorf("expected files copied to be 5, got %d", s.tallies["files copied"])
+	}
+	if s.tallies["bytes transferred"] != 1024 {
+		t.Errorf("expected bytes transferred to be 1024, got %d", s.tallies["bytes transferred"])
+	}
+}
+
+func TestBarMsgPerTransferRouting(t *testing.T) {
+	cmdCh := make(chan barMsg, 16)
+	b := barSend{cmdCh: cmdCh}
+
+	b.Extend("id-a", 100)
+	b.progress("id-a", 10)
+	b.Extend("id-b", 200)
+	b.progress("id-b", 20)
+	b.ErrorPut("id-a", 5)
+	b.ErrorGet("id-b", 7)
+	b.Resume("id-a", 50)
+	b.Categorize("files copied")
+	b.Tally("files copied", 1)
+	close(cmdCh)
+
+	want := []barMsg{
+		{Cmd: cpBarCmdExtend, ID: "id-a", Arg: int64(100)},
+		{Cmd: cpBarCmdProgress, ID: "id-a", Arg: int64(10)},
+		{Cmd: cpBarCmdExtend, ID: "id-b", Arg: int64(200)},
+		{Cmd: cpBarCmdProgress, ID: "id-b", Arg: int64(20)},
+		{Cmd: cpBarCmdPutError, ID: "id-a", Arg: int64(5)},
+		{Cmd: cpBarCmdGetError, ID: "id-b", Arg: int64(7)},
+		{Cmd: cpBarCmdResume, ID: "id-a", Arg: int64(50)},
+		{Cmd: cpBarCmdCategorize, ID: "", Arg: "files copied"},
+		{Cmd: cpBarCmdTally, ID: "", Arg: tallyArg{Category: "files copied", N: 1}},
+	}
+
+	i := 0
+	for msg := range cmdCh {
+		if i >= len(want) {
+			t.Fatalf("received unexpected extra message: %+v", msg)
+		}
+		if msg != want[i] {
+			t.Errorf("message %d: expected %+v, got %+v", i, want[i], msg)
+		}
+		i++
+	}
+	if i != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), i)
+	}
+}